@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Checkpoint фиксирует последний файл самого длинного хронологического
+// префикса полностью обработанных файлов: все файлы не позже него уже
+// обработаны (более новые файлы, завершившиеся раньше, в него не попадают,
+// пока не обработаны все предшествующие). При --resume используется, чтобы не
+// переобрабатывать файлы, которые были обработаны до сбоя предыдущего запуска.
+type Checkpoint struct {
+	LastFile    string    `json:"last_file"`
+	LastModTime time.Time `json:"last_mod_time"`
+}
+
+// loadCheckpoint читает checkpoint из path. Отсутствие файла не ошибка -
+// возвращается nil, что означает "с начала".
+func loadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения checkpoint файла %s: %v", path, err)
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("ошибка разбора checkpoint файла %s: %v", path, err)
+	}
+	return &cp, nil
+}
+
+// saveCheckpoint атомарно (через запись во временный файл и rename)
+// перезаписывает checkpoint файл.
+func saveCheckpoint(path string, cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации checkpoint: %v", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("ошибка записи checkpoint файла %s: %v", tmp, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// isProcessedByCheckpoint сообщает, что fi по хронологическому порядку
+// (modTime, при равенстве - path) не позже файла, зафиксированного в cp, и
+// поэтому должен быть пропущен при --resume.
+func isProcessedByCheckpoint(fi fileInfo, cp *Checkpoint) bool {
+	if cp == nil {
+		return false
+	}
+	if fi.modTime.Before(cp.LastModTime) {
+		return true
+	}
+	if fi.modTime.Equal(cp.LastModTime) {
+		return fi.path <= cp.LastFile
+	}
+	return false
+}
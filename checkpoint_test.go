@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsProcessedByCheckpoint(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cp := &Checkpoint{LastFile: "dir/2026-01-01_12.tsv.gz", LastModTime: base}
+
+	cases := []struct {
+		name string
+		fi   fileInfo
+		want bool
+	}{
+		{"nil checkpoint", fileInfo{path: "anything", modTime: base}, false},
+		{"strictly before", fileInfo{path: "dir/2025-12-31_23.tsv.gz", modTime: base.Add(-time.Hour)}, true},
+		{"strictly after", fileInfo{path: "dir/2026-01-01_13.tsv.gz", modTime: base.Add(time.Hour)}, false},
+		{"same modTime, path before or equal", fileInfo{path: "dir/2026-01-01_12.tsv.gz", modTime: base}, true},
+		{"same modTime, path after", fileInfo{path: "dir/2026-01-01_12a.tsv.gz", modTime: base}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got bool
+			if tc.name == "nil checkpoint" {
+				got = isProcessedByCheckpoint(tc.fi, nil)
+			} else {
+				got = isProcessedByCheckpoint(tc.fi, cp)
+			}
+			if got != tc.want {
+				t.Errorf("isProcessedByCheckpoint(%+v) = %v, want %v", tc.fi, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestDotRenamedFilesAreNeverReprocessed воспроизводит сценарий сбоя между
+// dotRename'ом файла и обновлением checkpoint: файл с точкой в начале
+// имени имеет modTime позже последнего checkpoint, поэтому одного только
+// isProcessedByCheckpoint недостаточно - glob-фильтр должен отсеивать его
+// по имени, не полагаясь на checkpoint.
+func TestDotRenamedFilesAreNeverReprocessed(t *testing.T) {
+	cp := &Checkpoint{
+		LastFile:    "dir/2026-01-01_12.tsv.gz",
+		LastModTime: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	dotRenamed := fileInfo{
+		path:    "dir/.2026-01-01_13.tsv.gz",
+		modTime: time.Date(2026, 1, 1, 13, 0, 0, 0, time.UTC),
+	}
+
+	if isProcessedByCheckpoint(dotRenamed, cp) {
+		t.Fatal("dotRenamed file unexpectedly treated as processed by checkpoint alone")
+	}
+
+	matched, err := filepath.Match("*.tsv.gz", filepath.Base(dotRenamed.path))
+	if err != nil {
+		t.Fatalf("unexpected error from filepath.Match: %v", err)
+	}
+	if !matched {
+		t.Fatal("expected filepath.Match to match a leading dot, confirming the need for an explicit skip")
+	}
+
+	if !strings.HasPrefix(filepath.Base(dotRenamed.path), ".") {
+		t.Fatal("dotRenamed file must be recognizable by its leading dot so the scan step can skip it")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetterWriter дописывает строки, для которых запись в sink не удалась
+// после исчерпания всех попыток, в gzip-сжатый TSV файл в исходном формате
+// входных данных, чтобы оператор мог переиграть их позже. Файл ротируется
+// по достижении MaxBytes.
+type DeadLetterWriter struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	size     int64
+	file     *os.File
+	gz       *gzip.Writer
+	w        *bufio.Writer
+}
+
+// NewDeadLetterWriter открывает (или создает) dead-letter файл по path.
+// maxBytes <= 0 отключает ротацию.
+func NewDeadLetterWriter(path string, maxBytes int64) (*DeadLetterWriter, error) {
+	d := &DeadLetterWriter{path: path, maxBytes: maxBytes}
+	if err := d.openLocked(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DeadLetterWriter) openLocked() error {
+	f, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия dead-letter файла %s: %v", d.path, err)
+	}
+
+	if info, statErr := f.Stat(); statErr == nil {
+		d.size = info.Size()
+	}
+
+	d.file = f
+	d.gz = gzip.NewWriter(f)
+	d.w = bufio.NewWriter(d.gz)
+	return nil
+}
+
+func (d *DeadLetterWriter) rotateLocked() error {
+	if err := d.w.Flush(); err != nil {
+		return err
+	}
+	if err := d.gz.Close(); err != nil {
+		return err
+	}
+	if err := d.file.Close(); err != nil {
+		return err
+	}
+
+	rotated := fmt.Sprintf("%s.%d", d.path, time.Now().UnixNano())
+	if err := os.Rename(d.path, rotated); err != nil {
+		return fmt.Errorf("ошибка ротации dead-letter файла %s: %v", d.path, err)
+	}
+
+	d.size = 0
+	return d.openLocked()
+}
+
+// WriteLine дописывает одну исходную строку в dead-letter файл.
+func (d *DeadLetterWriter) WriteLine(line string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.maxBytes > 0 && d.size >= d.maxBytes {
+		if err := d.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(d.w, "%s\n", line); err != nil {
+		return err
+	}
+
+	if err := d.w.Flush(); err != nil {
+		return err
+	}
+	if err := d.gz.Flush(); err != nil {
+		return err
+	}
+
+	return d.refreshSizeLocked()
+}
+
+// refreshSizeLocked обновляет d.size по реальному размеру файла на диске.
+// MaxBytes документирован как размер dead-letter файла, поэтому d.size
+// должен отражать сжатые байты, уже сброшенные на диск gzip.Writer'ом, а
+// не длину несжатых строк, переданных в WriteLine.
+func (d *DeadLetterWriter) refreshSizeLocked() error {
+	info, err := d.file.Stat()
+	if err != nil {
+		return fmt.Errorf("ошибка получения размера dead-letter файла %s: %v", d.path, err)
+	}
+	d.size = info.Size()
+	return nil
+}
+
+// Close сбрасывает буферы и закрывает dead-letter файл.
+func (d *DeadLetterWriter) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.w.Flush(); err != nil {
+		return err
+	}
+	if err := d.gz.Close(); err != nil {
+		return err
+	}
+	return d.file.Close()
+}
@@ -3,17 +3,20 @@ package main
 import (
 	"bufio"
 	"compress/gzip"
+	"context"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/bradfitz/gomemcache/memcache"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -21,6 +24,18 @@ const (
 	normalErrRate = 0.01
 )
 
+// logger - структурированный JSON логгер, использующийся во всем loader'е,
+// чтобы логи можно было отправлять в Loki/ELK. Переконфигурируется в main()
+// при указании --log.
+var logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+// fileInfo описывает входной файл и его время модификации, по которому
+// loader восстанавливает хронологический порядок обработки
+type fileInfo struct {
+	path    string
+	modTime time.Time
+}
+
 // AppsInstalled представляет распарсенную запись об установленных приложениях
 type AppsInstalled struct {
 	DevType string
@@ -40,12 +55,81 @@ type Config struct {
 	Dry     bool
 	LogFile string
 	Test    bool
+
+	IdfaSinkType string
+	GaidSinkType string
+	AdidSinkType string
+	DvidSinkType string
+
+	// OTLPByDevType хранит OTLP-настройки (заголовки, сжатие, TLS, батчинг)
+	// отдельно для каждого типа устройства, т.к. разные типы могут слать
+	// данные в разные OTLP-приемники с разной аутентификацией.
+	OTLPByDevType map[string]*OTLPFlags
+
+	RetryMaxAttempts    int
+	RetryInitialBackoff time.Duration
+	RetryMaxBackoff     time.Duration
+
+	DeadLetterFile     string
+	DeadLetterMaxBytes int64
+
+	MetricsAddr string
+
+	FileWorkers    int
+	CheckpointFile string
+	Resume         bool
+}
+
+// OTLPFlags - непарсенные флаги OTLP sink'а для одного типа устройства.
+// Headers парсится в map только при создании sink'а в buildSink.
+type OTLPFlags struct {
+	Headers         string
+	Compression     string
+	TLS             bool
+	CAFile          string
+	BatchMaxRecords int
+	BatchMaxWait    time.Duration
+}
+
+// parseHeaders разбирает список заголовков вида "key1=value1,key2=value2"
+func parseHeaders(raw string) map[string]string {
+	headers := map[string]string{}
+	if raw == "" {
+		return headers
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			continue
+		}
+		headers[kv[0]] = kv[1]
+	}
+	return headers
 }
 
-// MemcClient обертка над memcache клиентом для переиспользования соединений
-type MemcClient struct {
-	client *memcache.Client
-	addr   string
+// buildSink создает Sink нужного типа для типа устройства devType по его
+// адресу/пути addr. Поддерживаются memcached (по умолчанию), file и otlp.
+// otlpFlags - OTLP-настройки, специфичные для devType (игнорируются для
+// остальных типов sink'а).
+func buildSink(devType, addr, sinkType string, otlpFlags *OTLPFlags) (Sink, error) {
+	switch sinkType {
+	case "", "memcached":
+		return NewMemcSink(addr), nil
+	case "file":
+		return NewFileSink(addr)
+	case "otlp":
+		return NewOTLPSink(OTLPOptions{
+			Endpoint:        addr,
+			Headers:         parseHeaders(otlpFlags.Headers),
+			Compression:     otlpFlags.Compression,
+			TLS:             otlpFlags.TLS,
+			CAFile:          otlpFlags.CAFile,
+			BatchMaxRecords: otlpFlags.BatchMaxRecords,
+			BatchMaxWait:    otlpFlags.BatchMaxWait,
+		})
+	default:
+		return nil, fmt.Errorf("неизвестный тип sink для %s: %s", devType, sinkType)
+	}
 }
 
 // dotRename переименовывает файл, добавляя точку в начало имени
@@ -93,7 +177,7 @@ func parseAppsInstalled(line string) (*AppsInstalled, error) {
 		}
 		app, err := strconv.ParseUint(appStr, 10, 32)
 		if err != nil {
-			log.Printf("Не все app id являются числами: %s", line)
+			logger.Warn("не все app id являются числами", "line", line)
 			continue
 		}
 		apps = append(apps, uint32(app))
@@ -108,72 +192,73 @@ func parseAppsInstalled(line string) (*AppsInstalled, error) {
 	}, nil
 }
 
-// insertAppsInstalled записывает данные в memcached
-func insertAppsInstalled(client *MemcClient, appsInstalled *AppsInstalled, dry bool) error {
+// insertAppsInstalled записывает данные в целевой sink (memcached, файл или
+// OTLP) с повторными попытками согласно retryCfg. Если попытки исчерпаны,
+// исходная строка rawLine дописывается в deadLetter (если он задан), чтобы
+// оператор мог переиграть ее позже. Возвращает ключ записи, число сделанных
+// попыток, признак того, что запись ушла в dead-letter, и итоговую ошибку
+// (nil при успехе).
+func insertAppsInstalled(ctx context.Context, sink Sink, appsInstalled *AppsInstalled, rawLine string, dry bool, retryCfg RetryConfig, deadLetter *DeadLetterWriter) (key string, attempts int, deadLettered bool, err error) {
 	ua := &UserApps{
 		Lat:  proto.Float64(appsInstalled.Lat),
 		Lon:  proto.Float64(appsInstalled.Lon),
 		Apps: appsInstalled.Apps,
 	}
 
-	key := fmt.Sprintf("%s:%s", appsInstalled.DevType, appsInstalled.DevID)
+	key = fmt.Sprintf("%s:%s", appsInstalled.DevType, appsInstalled.DevID)
 	packed, err := proto.Marshal(ua)
 	if err != nil {
-		return fmt.Errorf("ошибка сериализации protobuf: %v", err)
+		return key, 0, false, fmt.Errorf("ошибка сериализации protobuf: %v", err)
 	}
 
 	if dry {
-		log.Printf("%s - %s -> lat=%f, lon=%f, apps=%v",
-			client.addr, key, appsInstalled.Lat, appsInstalled.Lon, appsInstalled.Apps)
-		return nil
+		logger.Info("dry run запись", "sink", sink.String(), "dev_type", appsInstalled.DevType, "key", key,
+			"lat", appsInstalled.Lat, "lon", appsInstalled.Lon, "apps", appsInstalled.Apps)
+		return key, 0, false, nil
 	}
 
-	err = client.client.Set(&memcache.Item{
-		Key:   key,
-		Value: packed,
-	})
+	start := time.Now()
+	attempts, err = writeWithRetry(ctx, sink, key, packed, retryCfg)
+	metricWriteDuration.WithLabelValues(appsInstalled.DevType).Observe(time.Since(start).Seconds())
+
 	if err != nil {
-		return fmt.Errorf("ошибка записи в memcache %s: %v", client.addr, err)
+		err = fmt.Errorf("ошибка записи в sink %s после %d попыток: %v", sink, attempts, err)
+		if deadLetter != nil {
+			if dlErr := deadLetter.WriteLine(rawLine); dlErr != nil {
+				logger.Error("ошибка записи в dead-letter файл", "error", dlErr)
+			} else {
+				deadLettered = true
+			}
+		}
 	}
 
-	return nil
+	return key, attempts, deadLettered, err
 }
 
 // processFile обрабатывает один файл
-func processFile(filename string, deviceMemc map[string]*MemcClient, dry bool, semaphore chan struct{}) {
-	log.Printf("Обработка файла %s", filename)
+func processFile(filename string, deviceSinks map[string]Sink, dry bool, semaphore chan struct{}, retryCfg RetryConfig, deadLetter *DeadLetterWriter) {
+	logger.Info("обработка файла", "filename", filename)
 
 	file, err := os.Open(filename)
 	if err != nil {
-		log.Printf("Ошибка открытия файла %s: %v", filename, err)
+		logger.Error("ошибка открытия файла", "filename", filename, "error", err)
 		return
 	}
 	defer file.Close()
 
 	gzipReader, err := gzip.NewReader(file)
 	if err != nil {
-		log.Printf("Ошибка создания gzip reader для %s: %v", filename, err)
+		logger.Error("ошибка создания gzip reader", "filename", filename, "error", err)
 		return
 	}
 	defer gzipReader.Close()
 
-	processed := 0
-	errors := 0
-	scanner := bufio.NewScanner(gzipReader)
+	var processed, writeErrors, retried, deadLettered atomic.Int64
+	parseErrors := 0
+	scanner := bufio.NewScanner(&countingReader{r: gzipReader})
 
 	var wg sync.WaitGroup
-	resultChan := make(chan bool, 1000)
-
-	// Горутина для подсчета результатов
-	go func() {
-		for ok := range resultChan {
-			if ok {
-				processed++
-			} else {
-				errors++
-			}
-		}
-	}()
+	ctx := context.Background()
 
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -181,55 +266,74 @@ func processFile(filename string, deviceMemc map[string]*MemcClient, dry bool, s
 			continue
 		}
 
+		metricLinesParsed.Inc()
+
 		appsInstalled, err := parseAppsInstalled(line)
 		if err != nil {
-			errors++
+			parseErrors++
+			metricParseErrors.Inc()
 			continue
 		}
 
-		memcClient, ok := deviceMemc[appsInstalled.DevType]
+		sink, ok := deviceSinks[appsInstalled.DevType]
 		if !ok {
-			errors++
-			log.Printf("Неизвестный тип устройства: %s", appsInstalled.DevType)
+			parseErrors++
+			metricParseErrors.Inc()
+			logger.Warn("неизвестный тип устройства", "filename", filename, "dev_type", appsInstalled.DevType)
 			continue
 		}
 
 		wg.Add(1)
 		// Ограничиваем количество параллельных операций через семафор
 		semaphore <- struct{}{}
-		go func(client *MemcClient, apps *AppsInstalled) {
+		go func(sink Sink, apps *AppsInstalled, rawLine string) {
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			err := insertAppsInstalled(client, apps, dry)
-			resultChan <- (err == nil)
+			key, attempts, wasDeadLettered, err := insertAppsInstalled(ctx, sink, apps, rawLine, dry, retryCfg, deadLetter)
+			if attempts > 1 {
+				retried.Add(int64(attempts - 1))
+				metricRetries.WithLabelValues(apps.DevType).Add(float64(attempts - 1))
+			}
 			if err != nil {
-				log.Printf("Ошибка вставки: %v", err)
+				writeErrors.Add(1)
+				logger.Error("ошибка вставки", "filename", filename, "dev_type", apps.DevType, "key", key, "error", err)
+				if wasDeadLettered {
+					deadLettered.Add(1)
+					metricDeadLettered.Inc()
+				}
+				return
 			}
-		}(memcClient, appsInstalled)
+
+			processed.Add(1)
+			metricRecordsWritten.WithLabelValues(apps.DevType).Inc()
+		}(sink, appsInstalled, line)
 	}
 
-	// Ждем завершения всех горутин
+	// Ждем завершения всех горутин. Счетчики - atomic, поэтому к этому
+	// моменту все инкременты гарантированно видны без дополнительной
+	// синхронизации через канал.
 	wg.Wait()
-	close(resultChan)
-
-	// Небольшая задержка чтобы горутина подсчета успела обработать все результаты
-	time.Sleep(100 * time.Millisecond)
 
 	if err := scanner.Err(); err != nil {
-		log.Printf("Ошибка чтения файла %s: %v", filename, err)
+		logger.Error("ошибка чтения файла", "filename", filename, "error", err)
 	}
 
-	if processed == 0 {
+	metricFilesProcessed.Inc()
+
+	if processed.Load() == 0 {
 		dotRename(filename)
 		return
 	}
 
-	errRate := float64(errors) / float64(processed)
+	totalErrors := int64(parseErrors) + writeErrors.Load()
+	errRate := float64(totalErrors) / float64(processed.Load())
+	logger.Info("итоги обработки файла", "filename", filename, "parse_errors", parseErrors,
+		"write_errors", writeErrors.Load(), "retried", retried.Load(), "dead_lettered", deadLettered.Load())
 	if errRate < normalErrRate {
-		log.Printf("Допустимый уровень ошибок (%f). Успешная загрузка", errRate)
+		logger.Info("допустимый уровень ошибок, успешная загрузка", "filename", filename, "err_rate", errRate)
 	} else {
-		log.Printf("Высокий уровень ошибок (%f > %f). Неудачная загрузка", errRate, normalErrRate)
+		logger.Warn("высокий уровень ошибок, неудачная загрузка", "filename", filename, "err_rate", errRate, "threshold", normalErrRate)
 	}
 
 	dotRename(filename)
@@ -265,17 +369,20 @@ gaid	7rfw452y52g2gq4g	55.55	42.42	7423,424`
 
 		packed, err := proto.Marshal(ua)
 		if err != nil {
-			log.Fatalf("Ошибка сериализации: %v", err)
+			logger.Error("ошибка сериализации", "error", err)
+			os.Exit(1)
 		}
 
 		unpacked := &UserApps{}
 		err = proto.Unmarshal(packed, unpacked)
 		if err != nil {
-			log.Fatalf("Ошибка десериализации: %v", err)
+			logger.Error("ошибка десериализации", "error", err)
+			os.Exit(1)
 		}
 
 		if !proto.Equal(ua, unpacked) {
-			log.Fatalf("Protobuf не совпадают")
+			logger.Error("protobuf не совпадают")
+			os.Exit(1)
 		}
 	}
 	fmt.Println("Protobuf тест пройден успешно")
@@ -288,84 +395,225 @@ func main() {
 	flag.StringVar(&config.LogFile, "log", "", "Путь к лог-файлу")
 	flag.BoolVar(&config.Dry, "dry", false, "Dry run режим (без записи в memcache)")
 	flag.StringVar(&config.Pattern, "pattern", "/data/appsinstalled/*.tsv.gz", "Паттерн для поиска файлов")
-	flag.StringVar(&config.Idfa, "idfa", "127.0.0.1:33013", "Адрес memcached для idfa")
-	flag.StringVar(&config.Gaid, "gaid", "127.0.0.1:33014", "Адрес memcached для gaid")
-	flag.StringVar(&config.Adid, "adid", "127.0.0.1:33015", "Адрес memcached для adid")
-	flag.StringVar(&config.Dvid, "dvid", "127.0.0.1:33016", "Адрес memcached для dvid")
+	flag.StringVar(&config.Idfa, "idfa", "127.0.0.1:33013", "Адрес/путь/endpoint sink'а для idfa")
+	flag.StringVar(&config.Gaid, "gaid", "127.0.0.1:33014", "Адрес/путь/endpoint sink'а для gaid")
+	flag.StringVar(&config.Adid, "adid", "127.0.0.1:33015", "Адрес/путь/endpoint sink'а для adid")
+	flag.StringVar(&config.Dvid, "dvid", "127.0.0.1:33016", "Адрес/путь/endpoint sink'а для dvid")
+
+	flag.StringVar(&config.IdfaSinkType, "idfa-sink", "memcached", "Тип sink'а для idfa: memcached, file, otlp")
+	flag.StringVar(&config.GaidSinkType, "gaid-sink", "memcached", "Тип sink'а для gaid: memcached, file, otlp")
+	flag.StringVar(&config.AdidSinkType, "adid-sink", "memcached", "Тип sink'а для adid: memcached, file, otlp")
+	flag.StringVar(&config.DvidSinkType, "dvid-sink", "memcached", "Тип sink'а для dvid: memcached, file, otlp")
+
+	// OTLP-настройки задаются отдельно для каждого типа устройства (идентично
+	// тому, как уже настраиваются endpoint и тип sink'а), т.к. разные типы
+	// устройств могут уходить в разные OTLP-приемники с разной аутентификацией.
+	config.OTLPByDevType = map[string]*OTLPFlags{"idfa": {}, "gaid": {}, "adid": {}, "dvid": {}}
+	for _, devType := range []string{"idfa", "gaid", "adid", "dvid"} {
+		f := config.OTLPByDevType[devType]
+		flag.StringVar(&f.Headers, devType+"-otlp-headers", "", fmt.Sprintf("Заголовки для OTLP sink'а %s вида key1=value1,key2=value2", devType))
+		flag.StringVar(&f.Compression, devType+"-otlp-compression", "none", fmt.Sprintf("Сжатие gRPC для OTLP sink'а %s: gzip, snappy, zstd, none", devType))
+		flag.BoolVar(&f.TLS, devType+"-otlp-tls", false, fmt.Sprintf("Использовать TLS для OTLP sink'а %s", devType))
+		flag.StringVar(&f.CAFile, devType+"-otlp-ca-file", "", fmt.Sprintf("Путь к CA сертификату для OTLP sink'а %s", devType))
+		flag.IntVar(&f.BatchMaxRecords, devType+"-otlp-batch-max-records", 100, fmt.Sprintf("Максимум записей в одном батче OTLP sink'а %s", devType))
+		flag.DurationVar(&f.BatchMaxWait, devType+"-otlp-batch-max-wait", time.Second, fmt.Sprintf("Максимальное время накопления батча OTLP sink'а %s", devType))
+	}
+
+	flag.IntVar(&config.RetryMaxAttempts, "retry-max-attempts", 3, "Максимум попыток записи одной записи в sink")
+	flag.DurationVar(&config.RetryInitialBackoff, "retry-initial-backoff", 100*time.Millisecond, "Начальная задержка перед повтором записи")
+	flag.DurationVar(&config.RetryMaxBackoff, "retry-max-backoff", 5*time.Second, "Максимальная задержка перед повтором записи")
+
+	flag.StringVar(&config.DeadLetterFile, "dead-letter-file", "", "Путь к gzip dead-letter файлу для записей, исчерпавших попытки (пусто - не писать)")
+	flag.Int64Var(&config.DeadLetterMaxBytes, "dead-letter-max-bytes", 100*1024*1024, "Размер dead-letter файла, после которого он ротируется")
+
+	flag.StringVar(&config.MetricsAddr, "metrics-addr", "", "Адрес HTTP сервера для /metrics (пусто - не поднимать)")
+
+	flag.IntVar(&config.FileWorkers, "file-workers", runtime.NumCPU(), "Число файлов, обрабатываемых параллельно")
+	flag.StringVar(&config.CheckpointFile, "checkpoint-file", "", "Путь к JSON checkpoint файлу (пусто - не писать)")
+	flag.BoolVar(&config.Resume, "resume", false, "Пропустить файлы, уже обработанные согласно checkpoint файлу")
 
 	flag.Parse()
 
-	// Настройка логирования
+	// Настройка логирования: JSON, чтобы логи можно было отправлять в Loki/ELK
 	if config.LogFile != "" {
 		logFile, err := os.OpenFile(config.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 		if err != nil {
-			log.Fatalf("Ошибка открытия лог-файла: %v", err)
+			logger.Error("ошибка открытия лог-файла", "error", err)
+			os.Exit(1)
 		}
 		defer logFile.Close()
-		log.SetOutput(logFile)
+		logger = slog.New(slog.NewJSONHandler(logFile, nil))
 	}
 
-	log.SetFlags(log.Ldate | log.Ltime)
-
 	if config.Test {
 		protoTest()
 		return
 	}
 
-	// Создаем клиенты memcached с постоянными соединениями
-	deviceMemc := map[string]*MemcClient{
-		"idfa": {client: memcache.New(config.Idfa), addr: config.Idfa},
-		"gaid": {client: memcache.New(config.Gaid), addr: config.Gaid},
-		"adid": {client: memcache.New(config.Adid), addr: config.Adid},
-		"dvid": {client: memcache.New(config.Dvid), addr: config.Dvid},
+	startMetricsServer(config.MetricsAddr)
+
+	// Создаем sink'и для каждого типа устройства согласно настроенному типу
+	sinkTypes := map[string]string{
+		"idfa": config.IdfaSinkType,
+		"gaid": config.GaidSinkType,
+		"adid": config.AdidSinkType,
+		"dvid": config.DvidSinkType,
+	}
+	addrs := map[string]string{
+		"idfa": config.Idfa,
+		"gaid": config.Gaid,
+		"adid": config.Adid,
+		"dvid": config.Dvid,
+	}
+
+	deviceSinks := map[string]Sink{}
+	for devType, addr := range addrs {
+		sink, err := buildSink(devType, addr, sinkTypes[devType], config.OTLPByDevType[devType])
+		if err != nil {
+			logger.Error("ошибка создания sink'а", "dev_type", devType, "error", err)
+			os.Exit(1)
+		}
+		deviceSinks[devType] = sink
+	}
+	defer func() {
+		for devType, sink := range deviceSinks {
+			if err := sink.Close(); err != nil {
+				logger.Error("ошибка закрытия sink'а", "dev_type", devType, "sink", sink.String(), "error", err)
+			}
+		}
+	}()
+
+	retryCfg := RetryConfig{
+		MaxAttempts:    config.RetryMaxAttempts,
+		InitialBackoff: config.RetryInitialBackoff,
+		MaxBackoff:     config.RetryMaxBackoff,
 	}
 
-	// Устанавливаем таймауты для клиентов
-	for _, client := range deviceMemc {
-		client.client.Timeout = 3 * time.Second
-		client.client.MaxIdleConns = 10
+	var deadLetter *DeadLetterWriter
+	if config.DeadLetterFile != "" {
+		dl, err := NewDeadLetterWriter(config.DeadLetterFile, config.DeadLetterMaxBytes)
+		if err != nil {
+			logger.Error("ошибка создания dead-letter файла", "error", err)
+			os.Exit(1)
+		}
+		deadLetter = dl
+		defer func() {
+			if err := deadLetter.Close(); err != nil {
+				logger.Error("ошибка закрытия dead-letter файла", "error", err)
+			}
+		}()
 	}
 
-	log.Printf("Memc loader запущен с параметрами: pattern=%s, dry=%v", config.Pattern, config.Dry)
+	logger.Info("memc loader запущен", "pattern", config.Pattern, "dry", config.Dry)
 
 	// Находим файлы по паттерну
 	files, err := filepath.Glob(config.Pattern)
 	if err != nil {
-		log.Fatalf("Ошибка поиска файлов: %v", err)
+		logger.Error("ошибка поиска файлов", "error", err)
+		os.Exit(1)
 	}
 
-	// Сортируем файлы по времени модификации (хронологический порядок)
-	type fileInfo struct {
-		path    string
-		modTime time.Time
-	}
+	// Собираем файлы и сортируем по времени модификации (хронологический порядок).
+	// filepath.Glob/Match, в отличие от шелла, не считает ведущую точку особой,
+	// поэтому уже dotRename'нутые файлы могут попасть в files повторно (например,
+	// если процесс упал после dotRename, но до обновления checkpoint) - пропускаем
+	// их явно по имени, а не полагаемся только на checkpoint.
 	var fileInfos []fileInfo
 	for _, file := range files {
+		if strings.HasPrefix(filepath.Base(file), ".") {
+			continue
+		}
+
 		info, err := os.Stat(file)
 		if err != nil {
-			log.Printf("Ошибка получения информации о файле %s: %v", file, err)
+			logger.Warn("ошибка получения информации о файле", "filename", file, "error", err)
 			continue
 		}
 		fileInfos = append(fileInfos, fileInfo{path: file, modTime: info.ModTime()})
 	}
 
-	// Простая сортировка по времени
-	for i := 0; i < len(fileInfos); i++ {
-		for j := i + 1; j < len(fileInfos); j++ {
-			if fileInfos[i].modTime.After(fileInfos[j].modTime) {
-				fileInfos[i], fileInfos[j] = fileInfos[j], fileInfos[i]
-			}
+	sort.Slice(fileInfos, func(i, j int) bool {
+		if fileInfos[i].modTime.Equal(fileInfos[j].modTime) {
+			return fileInfos[i].path < fileInfos[j].path
+		}
+		return fileInfos[i].modTime.Before(fileInfos[j].modTime)
+	})
+
+	var checkpoint *Checkpoint
+	if config.CheckpointFile != "" {
+		cp, err := loadCheckpoint(config.CheckpointFile)
+		if err != nil {
+			logger.Error("ошибка чтения checkpoint файла", "error", err)
+			os.Exit(1)
 		}
+		checkpoint = cp
+	}
+
+	if config.FileWorkers <= 0 {
+		logger.Warn("file-workers <= 0, использую 1", "requested", config.FileWorkers)
+		config.FileWorkers = 1
 	}
 
 	// Семафор для ограничения параллельных операций записи в memcached
 	semaphore := make(chan struct{}, 100)
 
-	// Обрабатываем файлы последовательно в хронологическом порядке
-	for _, fileInfo := range fileInfos {
-		processFile(fileInfo.path, deviceMemc, config.Dry, semaphore)
+	// Пул воркеров для параллельной обработки нескольких файлов одновременно,
+	// при этом write-семафор и sink'и (с их пулами соединений) остаются общими.
+	//
+	// Файлы обрабатываются параллельно и потому завершаются не в хронологическом
+	// порядке. completed отслеживает завершенность каждого файла по его
+	// позиции в отсортированном fileInfos, а checkpoint продвигается только
+	// по самому длинному завершенному префиксу - иначе при сбое после того как
+	// более новый файл случайно обогнал более старый, резюме пропустило бы
+	// необработанный старый файл.
+	completed := make([]bool, len(fileInfos))
+	for i, fi := range fileInfos {
+		if config.Resume && isProcessedByCheckpoint(fi, checkpoint) {
+			completed[i] = true
+		}
 	}
 
-	log.Println("Обработка завершена")
-}
+	fileSemaphore := make(chan struct{}, config.FileWorkers)
+	var fileWg sync.WaitGroup
+	var cpMu sync.Mutex
+
+	advanceCheckpointLocked := func() {
+		i := 0
+		for i < len(completed) && completed[i] {
+			i++
+		}
+		if i == 0 {
+			return
+		}
+		last := fileInfos[i-1]
+		checkpoint = &Checkpoint{LastFile: last.path, LastModTime: last.modTime}
+		if err := saveCheckpoint(config.CheckpointFile, *checkpoint); err != nil {
+			logger.Error("ошибка сохранения checkpoint файла", "error", err)
+		}
+	}
+
+	for idx, fi := range fileInfos {
+		if completed[idx] {
+			continue
+		}
+
+		fileWg.Add(1)
+		fileSemaphore <- struct{}{}
+		go func(idx int, fi fileInfo) {
+			defer fileWg.Done()
+			defer func() { <-fileSemaphore }()
 
+			processFile(fi.path, deviceSinks, config.Dry, semaphore, retryCfg, deadLetter)
+
+			cpMu.Lock()
+			defer cpMu.Unlock()
+			completed[idx] = true
+			if config.CheckpointFile != "" {
+				advanceCheckpointLocked()
+			}
+		}(idx, fi)
+	}
+	fileWg.Wait()
+
+	logger.Info("обработка завершена")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	metricLinesParsed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "memc_loader_lines_parsed_total",
+		Help: "Число строк входных файлов, для которых была предпринята попытка парсинга",
+	})
+	metricParseErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "memc_loader_parse_errors_total",
+		Help: "Число строк, которые не удалось распарсить или для которых не найден sink",
+	})
+	metricRecordsWritten = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "memc_loader_records_written_total",
+		Help: "Число записей, успешно записанных в sink, по типу устройства",
+	}, []string{"dev_type"})
+	metricWriteDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "memc_loader_write_duration_seconds",
+		Help:    "Время записи одной записи в sink, включая повторные попытки, по типу устройства",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"dev_type"})
+	metricRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "memc_loader_retries_total",
+		Help: "Число повторных попыток записи, по типу устройства",
+	}, []string{"dev_type"})
+	metricDeadLettered = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "memc_loader_dead_lettered_total",
+		Help: "Число записей, отправленных в dead-letter после исчерпания попыток",
+	})
+	metricFilesProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "memc_loader_files_processed_total",
+		Help: "Число обработанных входных файлов",
+	})
+	metricBytesRead = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "memc_loader_bytes_read_total",
+		Help: "Число прочитанных (разжатых) байт из входных файлов",
+	})
+)
+
+// startMetricsServer поднимает HTTP сервер с /metrics на addr. Пустой addr
+// отключает сервер.
+func startMetricsServer(addr string) {
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Error("metrics server остановлен", "error", err)
+		}
+	}()
+}
+
+// countingReader оборачивает io.Reader, накапливая число прочитанных байт
+// в metricBytesRead.
+type countingReader struct {
+	r io.Reader
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		metricBytesRead.Add(float64(n))
+	}
+	return n, err
+}
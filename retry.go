@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// RetryConfig описывает параметры повторных попыток записи в sink с
+// экспоненциальным backoff и джиттером.
+type RetryConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// isRetriableErr классифицирует ошибку sink'а: временные ошибки (таймаут,
+// отказ в соединении, временная ошибка сервера memcached) стоит повторить,
+// терминальные (некорректный ключ, слишком большое значение) — нет смысла,
+// они не исчезнут при повторной попытке.
+func isRetriableErr(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, memcache.ErrMalformedKey) {
+		return false
+	}
+	if errors.Is(err, memcache.ErrServerError) || errors.Is(err, memcache.ErrNoServers) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	msg := err.Error()
+	if strings.Contains(msg, "too large") || strings.Contains(msg, "object too large") {
+		return false
+	}
+	if strings.Contains(msg, "connection refused") || strings.Contains(msg, "timeout") || strings.Contains(msg, "EOF") {
+		return true
+	}
+
+	return false
+}
+
+// backoff возвращает задержку после неудачной attempt-й попытки (считая с 1),
+// то есть перед попыткой attempt+1:
+// экспоненциальный рост от InitialBackoff, ограниченный MaxBackoff, плюс
+// джиттер, чтобы повторные попытки разных горутин не совпадали по времени.
+func (c RetryConfig) backoff(attempt int) time.Duration {
+	d := c.InitialBackoff * time.Duration(int64(1)<<uint(attempt-1))
+	if c.MaxBackoff > 0 && d > c.MaxBackoff {
+		d = c.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// writeWithRetry пытается записать запись в sink до MaxAttempts раз,
+// повторяя только retriable ошибки. Возвращает число сделанных попыток и
+// последнюю ошибку (nil при успехе).
+func writeWithRetry(ctx context.Context, sink Sink, key string, value []byte, cfg RetryConfig) (int, error) {
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = sink.Write(ctx, key, value)
+		if err == nil {
+			return attempt, nil
+		}
+		if !isRetriableErr(err) || attempt == maxAttempts {
+			return attempt, err
+		}
+
+		select {
+		case <-time.After(cfg.backoff(attempt)):
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		}
+	}
+
+	return maxAttempts, err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func TestIsRetriableErr(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"malformed key terminal", memcache.ErrMalformedKey, false},
+		{"server error retriable", memcache.ErrServerError, true},
+		{"no servers retriable", memcache.ErrNoServers, true},
+		{"value too large terminal", errors.New("memcache: value too large"), false},
+		{"connection refused retriable", errors.New("dial tcp: connection refused"), true},
+		{"unrelated error terminal", errors.New("что-то совсем другое"), false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isRetriableErr(tc.err); got != tc.want {
+				t.Errorf("isRetriableErr(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetryConfigBackoff(t *testing.T) {
+	cfg := RetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := cfg.backoff(attempt)
+		if d < 0 {
+			t.Fatalf("backoff(%d) = %v, must not be negative", attempt, d)
+		}
+		if d > cfg.MaxBackoff {
+			t.Fatalf("backoff(%d) = %v, must not exceed MaxBackoff %v", attempt, d, cfg.MaxBackoff)
+		}
+	}
+}
+
+func TestWriteWithRetrySucceedsAfterTransientErrors(t *testing.T) {
+	calls := 0
+	sink := &fakeSink{writeFn: func() error {
+		calls++
+		if calls < 3 {
+			return memcache.ErrServerError
+		}
+		return nil
+	}}
+
+	cfg := RetryConfig{MaxAttempts: 5, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	attempts, err := writeWithRetry(context.Background(), sink, "key", []byte("value"), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestWriteWithRetryStopsOnTerminalError(t *testing.T) {
+	calls := 0
+	sink := &fakeSink{writeFn: func() error {
+		calls++
+		return memcache.ErrMalformedKey
+	}}
+
+	cfg := RetryConfig{MaxAttempts: 5, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	attempts, err := writeWithRetry(context.Background(), sink, "key", []byte("value"), cfg)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1 (no retry on terminal error)", attempts)
+	}
+	if calls != 1 {
+		t.Fatalf("sink was called %d times, want 1", calls)
+	}
+}
+
+// fakeSink - минимальная реализация Sink для модульных тестов retry-логики.
+type fakeSink struct {
+	writeFn func() error
+}
+
+func (s *fakeSink) Write(ctx context.Context, key string, value []byte) error {
+	return s.writeFn()
+}
+
+func (s *fakeSink) Close() error { return nil }
+
+func (s *fakeSink) String() string { return "fake" }
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	_ "github.com/mostynb/go-grpc-compression/nonclobbering/snappy"
+	_ "github.com/mostynb/go-grpc-compression/nonclobbering/zstd"
+	_ "google.golang.org/grpc/encoding/gzip"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// Sink абстрагирует место, куда loader пишет распарсенные записи.
+// Это позволяет заменить memcached на другое хранилище без изменения
+// логики чтения и парсинга файлов.
+type Sink interface {
+	Write(ctx context.Context, key string, value []byte) error
+	Close() error
+	String() string
+}
+
+// MemcSink пишет записи в memcached, как это делал loader изначально.
+type MemcSink struct {
+	client *memcache.Client
+	addr   string
+}
+
+// NewMemcSink создает sink поверх постоянного соединения с memcached.
+func NewMemcSink(addr string) *MemcSink {
+	client := memcache.New(addr)
+	client.Timeout = 3 * time.Second
+	client.MaxIdleConns = 10
+	return &MemcSink{client: client, addr: addr}
+}
+
+func (s *MemcSink) Write(ctx context.Context, key string, value []byte) error {
+	return s.client.Set(&memcache.Item{Key: key, Value: value})
+}
+
+func (s *MemcSink) Close() error {
+	return nil
+}
+
+func (s *MemcSink) String() string {
+	return fmt.Sprintf("memcached:%s", s.addr)
+}
+
+// FileSink пишет записи построчно в файл (или в stdout), удобно для
+// локальной отладки без поднятия memcached.
+type FileSink struct {
+	mu   sync.Mutex
+	w    *bufio.Writer
+	f    *os.File
+	path string
+}
+
+// NewFileSink открывает файл на запись в режиме дозаписи. Пустой путь
+// или "-" означает запись в stdout.
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "" || path == "-" {
+		return &FileSink{w: bufio.NewWriter(os.Stdout), path: "stdout"}, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла sink %s: %v", path, err)
+	}
+
+	return &FileSink{f: f, w: bufio.NewWriter(f), path: path}, nil
+}
+
+func (s *FileSink) Write(ctx context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "%s\t%s\n", key, base64.StdEncoding.EncodeToString(value))
+	return err
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	if s.f != nil {
+		return s.f.Close()
+	}
+	return nil
+}
+
+func (s *FileSink) String() string {
+	return fmt.Sprintf("file:%s", s.path)
+}
+
+// OTLPOptions описывает, как OTLPSink должен соединяться с приемником
+// и батчевать записи перед отправкой.
+type OTLPOptions struct {
+	Endpoint        string
+	Headers         map[string]string
+	Compression     string // gzip, snappy, zstd, none
+	TLS             bool
+	CAFile          string
+	BatchMaxRecords int
+	BatchMaxWait    time.Duration
+}
+
+type otlpRecord struct {
+	key    string
+	value  []byte
+	result chan<- error
+}
+
+// OTLPSink батчует записи и отправляет их gRPC-вызовом Export в сервис,
+// совместимый с OTLP Logs (например, коллектор observability-стека).
+// Каждая запись превращается в LogRecord с ключом в атрибутах и
+// исходным значением в теле.
+type OTLPSink struct {
+	opts    OTLPOptions
+	conn    *grpc.ClientConn
+	client  collogspb.LogsServiceClient
+	records chan otlpRecord
+	wg      sync.WaitGroup
+}
+
+// NewOTLPSink поднимает gRPC-соединение и запускает фоновый флашер,
+// который коалесцирует до BatchMaxRecords записей или BatchMaxWait
+// времени в один запрос Export.
+func NewOTLPSink(opts OTLPOptions) (*OTLPSink, error) {
+	if opts.BatchMaxRecords <= 0 {
+		opts.BatchMaxRecords = 100
+	}
+	if opts.BatchMaxWait <= 0 {
+		opts.BatchMaxWait = time.Second
+	}
+
+	var creds credentials.TransportCredentials
+	if opts.TLS {
+		tlsConfig := &tls.Config{}
+		if opts.CAFile != "" {
+			pool := x509.NewCertPool()
+			pem, err := os.ReadFile(opts.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка чтения CA файла %s: %v", opts.CAFile, err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("не удалось разобрать CA файл %s", opts.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	} else {
+		creds = insecure.NewCredentials()
+	}
+
+	conn, err := grpc.NewClient(opts.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка установки gRPC соединения с %s: %v", opts.Endpoint, err)
+	}
+
+	sink := &OTLPSink{
+		opts:    opts,
+		conn:    conn,
+		client:  collogspb.NewLogsServiceClient(conn),
+		records: make(chan otlpRecord, opts.BatchMaxRecords),
+	}
+
+	sink.wg.Add(1)
+	go sink.flushLoop()
+
+	return sink, nil
+}
+
+// Write ставит запись в очередь на батчинг и блокируется до тех пор, пока
+// батч, в который она попала, не будет отправлен - только так вызывающий
+// код (retry/dead-letter/метрики) видит реальный результат Export, а не
+// факт постановки в очередь.
+func (s *OTLPSink) Write(ctx context.Context, key string, value []byte) error {
+	result := make(chan error, 1)
+
+	select {
+	case s.records <- otlpRecord{key: key, value: value, result: result}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *OTLPSink) flushLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.opts.BatchMaxWait)
+	defer ticker.Stop()
+
+	batch := make([]otlpRecord, 0, s.opts.BatchMaxRecords)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.export(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case rec, ok := <-s.records:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, rec)
+			if len(batch) >= s.opts.BatchMaxRecords {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// export отправляет батч через gRPC Export и сообщает результат (успех или
+// единую ошибку для всего батча) каждой записи через ее result-канал, чтобы
+// Write вернул вызывающему коду реальный исход, а не факт постановки в очередь.
+func (s *OTLPSink) export(batch []otlpRecord) {
+	logRecords := make([]*logspb.LogRecord, 0, len(batch))
+	for _, rec := range batch {
+		logRecords = append(logRecords, &logspb.LogRecord{
+			Attributes: []*commonpb.KeyValue{
+				{
+					Key:   "key",
+					Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: rec.key}},
+				},
+			},
+			Body: &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: rec.value}},
+		})
+	}
+
+	req := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{
+			{
+				ScopeLogs: []*logspb.ScopeLogs{
+					{LogRecords: logRecords},
+				},
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.BatchMaxWait+5*time.Second)
+	defer cancel()
+
+	for k, v := range s.opts.Headers {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+	}
+
+	var callOpts []grpc.CallOption
+	if s.opts.Compression != "" && s.opts.Compression != "none" {
+		callOpts = append(callOpts, grpc.UseCompressor(s.opts.Compression))
+	}
+
+	_, err := s.client.Export(ctx, req, callOpts...)
+	if err != nil {
+		logger.Error("ошибка отправки записей в OTLP приемник", "endpoint", s.opts.Endpoint, "batch_size", len(batch), "error", err)
+	}
+
+	for _, rec := range batch {
+		rec.result <- err
+	}
+}
+
+func (s *OTLPSink) Close() error {
+	close(s.records)
+	s.wg.Wait()
+	return s.conn.Close()
+}
+
+func (s *OTLPSink) String() string {
+	return fmt.Sprintf("otlp:%s", s.opts.Endpoint)
+}